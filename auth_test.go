@@ -0,0 +1,85 @@
+package ticket
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flipLast returns s with its last byte changed to a different character,
+// for tamper tests that don't want to depend on what that byte happened
+// to be.
+func flipLast(s string) string {
+	repl := byte('0')
+	if s[len(s)-1] == '0' {
+		repl = '1'
+	}
+	return s[:len(s)-1] + string(repl)
+}
+
+func TestVerifyRejectsExpiredTicket(t *testing.T) {
+
+	m := NewTicket(t.TempDir()).WithKey([]byte("secret"))
+
+	var tkt [18]byte
+	rand.Read(tkt[:])
+	expired := m.authenticate(tkt, time.Now().Add(-time.Hour).Unix())
+
+	if m.verify(expired) {
+		t.Fatal("expected expired ticket to fail verification")
+	}
+}
+
+func TestVerifyRejectsTamperedTag(t *testing.T) {
+
+	m := NewTicket(t.TempDir()).WithKey([]byte("secret"))
+
+	var tkt [18]byte
+	rand.Read(tkt[:])
+	valid := m.authenticate(tkt, time.Now().Add(time.Hour).Unix())
+
+	if !m.verify(valid) {
+		t.Fatal("expected freshly generated ticket to verify")
+	}
+	if m.verify(flipLast(valid)) {
+		t.Fatal("expected tampered ticket to fail verification")
+	}
+}
+
+func TestAuthenticatedSaveLoadRoundTrip(t *testing.T) {
+
+	m := NewTicket(t.TempDir()).WithKey([]byte("secret"))
+	ttl := time.Hour
+
+	name, ok := m.Save(nil, strings.NewReader("payload"), &ttl)
+	if !ok {
+		t.Fatal("save failed")
+	}
+
+	var buf bytes.Buffer
+	if !m.Load(&name, &buf) {
+		t.Fatal("expected load to succeed for a valid authenticated ticket")
+	}
+	if buf.String() != "payload" {
+		t.Fatalf("got %q, want %q", buf.String(), "payload")
+	}
+}
+
+func TestLoadRejectsTamperedTicket(t *testing.T) {
+
+	m := NewTicket(t.TempDir()).WithKey([]byte("secret"))
+	ttl := time.Hour
+
+	name, ok := m.Save(nil, strings.NewReader("payload"), &ttl)
+	if !ok {
+		t.Fatal("save failed")
+	}
+
+	tampered := flipLast(name)
+	var buf bytes.Buffer
+	if m.Load(&tampered, &buf) {
+		t.Fatal("expected tampered ticket to be rejected by Load")
+	}
+}
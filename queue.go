@@ -0,0 +1,282 @@
+package ticket
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Durable FIFO queue mode (Queue). Enqueued tickets are appended to a
+// segmented, append-only log so Next(false) survives restarts and scales
+// past an in-memory directory listing.
+//
+// segment file: qlog.NNNN, capped at queueSegmentSize bytes
+// record:       uvarint ticketLen | ticket bytes | int64 enqueueUnixNano
+// head cursor:  qhead, [4]byte segment id | [8]byte byte offset, both
+//
+//	big-endian, rewritten atomically via temp-file + rename
+//	after each successful Next(false)
+const (
+	queueSegmentPrefix = "qlog."
+	queueSegmentSize   = 64 << 20 // 64 MiB
+	queueHeadFile      = "qhead"
+
+	// maxTicketRecordLen bounds a record's declared ticket length; real
+	// tickets run well under 100 bytes, so anything past this is a torn
+	// or garbled record rather than a real one.
+	maxTicketRecordLen = 1024
+)
+
+// isIndexFile reports whether name is ticketer bookkeeping rather than a
+// ticket, so Next, Migrate and the Wait/Subscribe directory scans don't
+// mistake it for one.
+func isIndexFile(name string) bool {
+	switch {
+	case name == ttlIndexFile, name == queueHeadFile:
+		return true
+	case strings.HasPrefix(name, queueSegmentPrefix):
+		return true
+	case strings.HasPrefix(name, ".ticket.ttl."), strings.HasPrefix(name, ".qhead."):
+		return true
+	default:
+		return false
+	}
+}
+
+// Sync toggles fsync after each queue append; off by default
+func (m *Ticket) Sync(on bool) *Ticket { m.qSync = on; return m }
+
+func (m *Ticket) segmentPath(id int) string {
+	return filepath.Join(m.Path, fmt.Sprintf("%s%04d", queueSegmentPrefix, id))
+}
+
+func (m *Ticket) headPath() string { return filepath.Join(m.Path, queueHeadFile) }
+
+// discoverSegment scans m.Path for the highest-numbered qlog segment, or 0
+// when none exist yet; used to resume the tail after a restart.
+func (m *Ticket) discoverSegment() int {
+
+	de, err := os.ReadDir(m.Path)
+	if err != nil {
+		return 0
+	}
+
+	max := 0
+	for _, e := range de {
+		var id int
+		if n, err := fmt.Sscanf(e.Name(), queueSegmentPrefix+"%04d", &id); n == 1 && err == nil && id > max {
+			max = id
+		}
+	}
+	return max
+}
+
+// enqueue appends ticket to the tail segment, rolling over to a fresh
+// segment once the current one reaches queueSegmentSize.
+func (m *Ticket) enqueue(ticket string) {
+
+	m.qMu.Lock()
+	defer m.qMu.Unlock()
+
+	if m.qTail < 0 {
+		m.qTail = m.discoverSegment()
+	}
+
+	path := m.segmentPath(m.qTail)
+	if fi, err := os.Stat(path); err == nil && fi.Size() >= queueSegmentSize {
+		m.qTail++
+		path = m.segmentPath(m.qTail)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var lenbuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenbuf[:], uint64(len(ticket)))
+	f.Write(lenbuf[:n])
+	f.WriteString(ticket)
+
+	var tsbuf [8]byte
+	binary.BigEndian.PutUint64(tsbuf[:], uint64(time.Now().UnixNano()))
+	f.Write(tsbuf[:])
+
+	if m.qSync {
+		f.Sync()
+	}
+}
+
+// readUvarint decodes a single uvarint from r, returning the value and the
+// number of bytes consumed so the head cursor can advance precisely.
+func readUvarint(r io.Reader) (value uint64, n int, err error) {
+
+	var b [1]byte
+	var shift uint
+	for {
+		if _, err = io.ReadFull(r, b[:]); err != nil {
+			return 0, n, err
+		}
+		n++
+		if b[0] < 0x80 {
+			value |= uint64(b[0]) << shift
+			return value, n, nil
+		}
+		value |= uint64(b[0]&0x7f) << shift
+		shift += 7
+	}
+}
+
+// readHeadCursorLocked loads the head cursor from disk; callers must hold
+// qMu. Missing or short files start the cursor at segment 0, offset 0.
+func (m *Ticket) readHeadCursorLocked() {
+
+	data, err := os.ReadFile(m.headPath())
+	if err != nil || len(data) < 12 {
+		m.qHeadSeg, m.qHeadOff = 0, 0
+		return
+	}
+
+	m.qHeadSeg = int(binary.BigEndian.Uint32(data[:4]))
+	m.qHeadOff = int64(binary.BigEndian.Uint64(data[4:12]))
+}
+
+// writeHeadCursorLocked persists the head cursor atomically; callers must
+// hold qMu.
+func (m *Ticket) writeHeadCursorLocked() {
+
+	var buf [12]byte
+	binary.BigEndian.PutUint32(buf[:4], uint32(m.qHeadSeg))
+	binary.BigEndian.PutUint64(buf[4:], uint64(m.qHeadOff))
+
+	tmp, err := os.CreateTemp(m.Path, ".qhead.*")
+	if err != nil {
+		return
+	}
+	tmp.Write(buf[:])
+	name := tmp.Name()
+	tmp.Close()
+	os.Rename(name, m.headPath())
+}
+
+// loadHeadCursor loads the persisted head cursor once; safe to call
+// repeatedly, called from Start so a restart resumes where it left off.
+func (m *Ticket) loadHeadCursor() {
+
+	m.qMu.Lock()
+	defer m.qMu.Unlock()
+
+	if m.qHeadSeg >= 0 {
+		return
+	}
+	m.readHeadCursorLocked()
+}
+
+// queueNext returns the next ticket in enqueue order from the durable FIFO
+// log, advancing and persisting the head cursor, and skipping any ticket
+// whose file has already been removed.
+func (m *Ticket) queueNext() *string {
+
+	m.qMu.Lock()
+	defer m.qMu.Unlock()
+
+	if m.qHeadSeg < 0 {
+		m.readHeadCursorLocked()
+	}
+
+	for {
+		f, err := os.Open(m.segmentPath(m.qHeadSeg))
+		if err != nil {
+			if m.qHeadSeg >= m.discoverSegment() {
+				return nil // caught up to the tail
+			}
+			m.qHeadSeg++
+			m.qHeadOff = 0
+			m.writeHeadCursorLocked()
+			continue
+		}
+
+		info, _ := f.Stat()
+		if info == nil || m.qHeadOff >= info.Size() {
+			f.Close()
+			if m.qHeadSeg >= m.discoverSegment() {
+				return nil // caught up to the tail
+			}
+			m.qHeadSeg++
+			m.qHeadOff = 0
+			m.writeHeadCursorLocked()
+			continue
+		}
+
+		f.Seek(m.qHeadOff, io.SeekStart)
+		size := info.Size()
+
+		ticketLen, n, err := readUvarint(f)
+		if err != nil || ticketLen > maxTicketRecordLen {
+			// a torn write at the tail (crash mid-append with Sync off)
+			// leaves a short or garbled record; skip past it so future
+			// appends resync cleanly instead of wedging the cursor here
+			f.Close()
+			m.qHeadOff = size
+			m.writeHeadCursorLocked()
+			continue
+		}
+
+		ticketBuf := make([]byte, ticketLen)
+		if _, err := io.ReadFull(f, ticketBuf); err != nil {
+			f.Close()
+			m.qHeadOff = size
+			m.writeHeadCursorLocked()
+			continue
+		}
+
+		var tsbuf [8]byte
+		if _, err := io.ReadFull(f, tsbuf[:]); err != nil {
+			f.Close()
+			m.qHeadOff = size
+			m.writeHeadCursorLocked()
+			continue
+		}
+		f.Close()
+
+		m.qHeadOff += int64(n) + int64(ticketLen) + int64(len(tsbuf))
+		m.writeHeadCursorLocked()
+
+		ticket := string(ticketBuf)
+		if _, err := os.Stat(m.getPath(ticket)); err != nil {
+			continue // already removed; skip to the next record
+		}
+		return &ticket
+	}
+}
+
+// compactQueueSegments drops segments entirely behind the head cursor;
+// run from Start's hourly tick so a long-lived queue doesn't accumulate
+// fully-consumed segments.
+func (m *Ticket) compactQueueSegments() {
+
+	m.qMu.Lock()
+	head := m.qHeadSeg
+	m.qMu.Unlock()
+
+	if head <= 0 {
+		return
+	}
+
+	de, err := os.ReadDir(m.Path)
+	if err != nil {
+		return
+	}
+
+	for _, e := range de {
+		var id int
+		if n, err := fmt.Sscanf(e.Name(), queueSegmentPrefix+"%04d", &id); n == 1 && err == nil && id < head {
+			os.Remove(filepath.Join(m.Path, e.Name()))
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package ticket
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// shardWorkers caps how many shard directories are walked concurrently by
+// nextSharded's random selection.
+const shardWorkers = 8
+
+// Shard switches on the sharded directory layout: tickets are routed into
+// m.Path/xx/<ticket> instead of m.Path/<ticket>, keeping any one directory
+// from growing past what ext4/xfs (or tmpfs) handle well. n should be a
+// power of two, e.g. 256. The shard directories are created immediately;
+// use Migrate to move an existing flat layout into them.
+func (m *Ticket) Shard(n int) *Ticket {
+	m.shards = n
+	m.makeShardDirs()
+	return m
+}
+
+func (m *Ticket) makeShardDirs() {
+	for _, dir := range m.shardDirs() {
+		os.MkdirAll(filepath.Join(m.Path, dir), 0755)
+	}
+}
+
+func (m *Ticket) shardDirs() []string {
+	dirs := make([]string, m.shards)
+	for i := range dirs {
+		dirs[i] = fmt.Sprintf("%02x", i)
+	}
+	return dirs
+}
+
+// shardDir returns the shard directory name for ticket, bucketing on the
+// first byte of its random suffix so queue-mode's monotonic sequence
+// prefix doesn't skew the load.
+func (m *Ticket) shardDir(ticket string) string {
+	return fmt.Sprintf("%02x", int(shardKey(ticket))%m.shards)
+}
+
+// shardKey extracts the first byte of a ticket's random suffix: for plain
+// tickets that's the first byte of the 4th dash-separated group (tkt[8:10]
+// in Generate's layout); for HMAC-authenticated tickets (see auth.go) it's
+// payload[8], the same offset within the embedded header.
+func shardKey(ticket string) byte {
+
+	if payloadHex, _, ok := strings.Cut(ticket, "."); ok {
+		if payload, err := hex.DecodeString(payloadHex); err == nil && len(payload) > 8 {
+			return payload[8]
+		}
+		return 0
+	}
+
+	parts := strings.Split(ticket, "-")
+	if len(parts) >= 4 && len(parts[3]) >= 2 {
+		if b, err := hex.DecodeString(parts[3][:2]); err == nil {
+			return b[0]
+		}
+	}
+	return 0
+}
+
+// nextSharded is Next's shard-aware counterpart, walking m.Path/xx
+// directories instead of a single flat directory.
+func (m *Ticket) nextSharded(random bool) *string {
+
+	if !random {
+		if atomic.LoadInt32(&m.sequence) > -1 {
+			return m.queueNext()
+		}
+		for _, dir := range m.shardDirs() {
+			base := filepath.Join(m.Path, dir)
+			f, err := os.Open(base)
+			if err != nil {
+				continue
+			}
+			de, err := f.ReadDir(1000)
+			f.Close()
+			if err != nil {
+				continue
+			}
+			for _, e := range de {
+				if e.Type().IsRegular() && !isIndexFile(e.Name()) {
+					path := filepath.Join(base, e.Name())
+					return &path
+				}
+			}
+		}
+		return nil
+	}
+
+	type hit struct{ dir, name string }
+
+	dirs := m.shardDirs()
+	jobs := make(chan string, len(dirs))
+	for _, dir := range dirs {
+		jobs <- dir
+	}
+	close(jobs)
+
+	results := make(chan []hit, len(dirs))
+
+	workers := shardWorkers
+	if workers > len(dirs) {
+		workers = len(dirs)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dir := range jobs {
+				de, err := os.ReadDir(filepath.Join(m.Path, dir))
+				if err != nil {
+					results <- nil
+					continue
+				}
+				var hits []hit
+				for _, e := range de {
+					if e.Type().IsRegular() && !isIndexFile(e.Name()) {
+						hits = append(hits, hit{dir, e.Name()})
+					}
+				}
+				results <- hits
+			}
+		}()
+	}
+
+	go func() { wg.Wait(); close(results) }()
+
+	var all []hit
+	for hits := range results {
+		all = append(all, hits...)
+	}
+	if len(all) == 0 {
+		return nil
+	}
+
+	var b [8]byte
+	rand.Read(b[:])
+	pick := all[binary.LittleEndian.Uint64(b[:])%uint64(len(all))]
+	path := filepath.Join(m.Path, pick.dir, pick.name)
+	return &path
+}
+
+// Migrate moves an existing flat layout into the sharded one, so callers
+// can adopt Shard without losing in-flight tickets. Shard must be called
+// first. Safe to call more than once; already-sharded tickets are left in
+// place.
+func (m *Ticket) Migrate() error {
+
+	if m.shards <= 0 {
+		return errors.New("ticket: Shard must be set before Migrate")
+	}
+
+	de, err := os.ReadDir(m.Path)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range de {
+		if !e.Type().IsRegular() || isIndexFile(e.Name()) {
+			continue
+		}
+		os.Rename(filepath.Join(m.Path, e.Name()), m.getPath(e.Name()))
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package ticket
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShardDirRouting(t *testing.T) {
+
+	m := NewTicket(t.TempDir()).Shard(16)
+
+	ticket := m.Generate(nil)
+	dir := m.shardDir(ticket)
+
+	want := fmt.Sprintf("%02x", int(shardKey(ticket))%16)
+	if dir != want {
+		t.Fatalf("shardDir = %q, want %q", dir, want)
+	}
+
+	if got := filepath.Dir(m.getPath(ticket)); got != filepath.Join(m.Path, dir) {
+		t.Fatalf("getPath routed to %q, expected shard dir %q", got, dir)
+	}
+}
+
+func TestMigrateMovesFlatLayoutIntoShards(t *testing.T) {
+
+	dir := t.TempDir()
+	m := NewTicket(dir)
+
+	name, ok := m.Save(nil, strings.NewReader("payload"), nil)
+	if !ok {
+		t.Fatal("save failed")
+	}
+
+	m.Shard(8)
+	if err := m.Migrate(); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+		t.Fatalf("expected flat-layout file to be moved, stat err=%v", err)
+	}
+	if _, err := os.Stat(m.getPath(name)); err != nil {
+		t.Fatalf("expected ticket at its sharded path, stat err=%v", err)
+	}
+}
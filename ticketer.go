@@ -10,6 +10,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -19,6 +20,19 @@ type Ticket struct {
 	Path     string        // default is /tmp
 	ttl      time.Duration // default is 12 hours
 	sequence int32         // default is off; -1
+
+	ttlMu    sync.RWMutex
+	ttlIndex map[string]int64 // ticket -> unix expiry, backed by the .ticket.ttl index
+
+	key []byte // set via WithKey; turns on HMAC-authenticated tickets
+
+	qMu      sync.Mutex // guards both the tail append and the head cursor, so a read can never observe an in-flight, partially written record
+	qSync    bool       // fsync after each append, set via Sync(true)
+	qTail    int        // current tail segment id; -1 until discovered
+	qHeadSeg int        // cached head segment id; -1 until loaded
+	qHeadOff int64      // cached head byte offset within qHeadSeg
+
+	shards int // set via Shard; 0 means the flat, unsharded layout
 }
 
 // NewTicket is the *Ticket configurator; provides assurance Path exists
@@ -26,18 +40,25 @@ func NewTicket(path string) *Ticket {
 	if _, err := os.Stat(path); !errors.Is(err, fs.ErrExist) {
 		os.MkdirAll(path, 0755)
 	}
-	return &Ticket{Path: path, sequence: -1}
+	return &Ticket{Path: path, sequence: -1, ttlIndex: make(map[string]int64), qTail: -1, qHeadSeg: -1}
 }
 
-// getPath assures a valid composite ticketed uuid path is returned
+// getPath assures a valid composite ticketed uuid path is returned; when
+// Shard is set, tickets are routed into m.Path/xx/<ticket> (see shard.go)
 func (m *Ticket) getPath(ticket string) string {
-	return filepath.Join(m.Path, filepath.Base(ticket))
+	name := filepath.Base(ticket)
+	if m.shards > 0 {
+		return filepath.Join(m.Path, m.shardDir(name), name)
+	}
+	return filepath.Join(m.Path, name)
 }
 
 // Queue turns queue sequencer on
 func (m *Ticket) Queue() *Ticket { atomic.CompareAndSwapInt32(&m.sequence, -1, 0); return m }
 
-// Generate new ticket uuid; concurrency safe
+// Generate new ticket uuid; concurrency safe. When WithKey has been set,
+// the ticket instead carries an embedded expiry and HMAC tag (see auth.go);
+// ttl overrides the default expiration used for that embedded expiry.
 //
 // random   :0  e4e45937-79c9-c3b4-07e4-7c13d989f9235e15
 // sequence :1+ 00000001-5d9b-95d2-de8d-9c7cb21451fac9c1
@@ -47,7 +68,7 @@ func (m *Ticket) Queue() *Ticket { atomic.CompareAndSwapInt32(&m.sequence, -1, 0
 // [2]byte low 32bit unix time
 // [2]byte random uint16
 // [8]byte random uint64
-func (m *Ticket) Generate() string {
+func (m *Ticket) Generate(ttl *time.Duration) string {
 
 	var tkt [18]byte
 	if atomic.LoadInt32(&m.sequence) > -1 {
@@ -59,37 +80,72 @@ func (m *Ticket) Generate() string {
 		rand.Read(tkt[:])
 	}
 
-	return fmt.Sprintf("%x-%x-%x-%x-%x", tkt[0:4], tkt[4:6], tkt[6:8], tkt[8:10], tkt[10:])
+	if len(m.key) == 0 {
+		return fmt.Sprintf("%x-%x-%x-%x-%x", tkt[0:4], tkt[4:6], tkt[6:8], tkt[8:10], tkt[10:])
+	}
+
+	expires := m.defaultTTL()
+	if ttl != nil {
+		expires = *ttl
+	}
+
+	return m.authenticate(tkt, time.Now().Add(expires).Unix())
 }
 
-// Writer creates the ticketed file and returns an io.WriteCloser
-func (m *Ticket) Writer(ticket *string) (io.WriteCloser, bool) {
+// Writer creates the ticketed file and returns an io.WriteCloser; ttl overrides
+// the default expiration (see Expire) for this ticket alone when non-nil.
+// When WithKey is set, the ticket's HMAC tag and embedded expiry are
+// verified before the file is touched.
+func (m *Ticket) Writer(ticket *string, ttl *time.Duration) (io.WriteCloser, bool) {
+
+	if len(m.key) > 0 && !m.verify(*ticket) {
+		return nil, false
+	}
 
 	writer, err := os.Create(m.getPath(*ticket))
-	return writer, err == nil
+	if err != nil {
+		return writer, false
+	}
+
+	expires := m.defaultTTL()
+	if ttl != nil {
+		expires = *ttl
+	}
+	m.setExpiry(*ticket, time.Now().Add(expires))
+
+	return writer, true
 
 }
 
-// Reader opens the ticketed file and returns an io.ReadCloser
+// Reader opens the ticketed file and returns an io.ReadCloser; when WithKey
+// is set, the ticket's HMAC tag and embedded expiry are verified first
 func (m *Ticket) Reader(ticket *string) (io.ReadCloser, bool) {
 
+	if len(m.key) > 0 && !m.verify(*ticket) {
+		return nil, false
+	}
+
 	reader, err := os.Open(m.getPath(*ticket))
 	return reader, err == nil
 
 }
 
-// Save writes data as a ticketed file from io.Reader
-func (m *Ticket) Save(ticket *string, reader io.Reader) (string, bool) {
+// Save writes data as a ticketed file from io.Reader; ttl overrides the
+// default expiration (see Expire) for this ticket alone when non-nil
+func (m *Ticket) Save(ticket *string, reader io.Reader, ttl *time.Duration) (string, bool) {
 
 	if ticket == nil {
-		tkt := m.Generate()
+		tkt := m.Generate(ttl)
 		ticket = &tkt
 	}
 
-	qf, ok := m.Writer(ticket)
+	qf, ok := m.Writer(ticket, ttl)
 	if ok {
 		io.Copy(qf, reader)
 		qf.Close()
+		if atomic.LoadInt32(&m.sequence) > -1 {
+			m.enqueue(*ticket)
+		}
 	}
 
 	return *ticket, ok
@@ -107,45 +163,78 @@ func (m *Ticket) Load(ticket *string, writer io.Writer) bool {
 	return ok
 }
 
-// Remove a ticketed file from m.Path
-func (m *Ticket) Remove(ticket *string) bool { return os.Remove(m.getPath(*ticket)) == nil }
+// Remove a ticketed file from m.Path; when WithKey is set, the ticket's
+// HMAC tag and embedded expiry are verified first
+func (m *Ticket) Remove(ticket *string) bool {
 
-// Next returns the next ticket for processing from m.Path reading in
-// directory order, not necessarily fifo; or random selection mixing
-func (m *Ticket) Next(random bool) *string {
+	if len(m.key) > 0 && !m.verify(*ticket) {
+		return false
+	}
 
-	var path string
-	f, _ := os.Open(m.Path)
-	de, err := f.ReadDir(1000)
-	f.Close()
+	ok := os.Remove(m.getPath(*ticket)) == nil
+	m.clearExpiry(*ticket)
+	return ok
+}
 
-	if err != nil || len(de) == 0 {
-		return nil
+// Next returns the next ticket for processing. In queue mode (Queue), false
+// walks the durable FIFO log in enqueue order (see queue.go); outside queue
+// mode it falls back to directory order, not necessarily fifo. true is
+// random selection mixing, scanning m.Path in full rather than the first
+// 1000 entries.
+func (m *Ticket) Next(random bool) *string {
+
+	if m.shards > 0 {
+		return m.nextSharded(random)
 	}
 
-	if !random { // head from directory order
-		path = filepath.Join(m.Path, de[0].Name())
+	if random {
+		de, err := os.ReadDir(m.Path)
+		if err != nil {
+			return nil
+		}
+
+		var candidates []string
+		for _, e := range de {
+			if e.Type().IsRegular() && !isIndexFile(e.Name()) {
+				candidates = append(candidates, e.Name())
+			}
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
 
-	} else {
 		var b [8]byte
 		rand.Read(b[:]) // generate random uint64, use modulus math for random selection
-		path = filepath.Join(m.Path, de[binary.LittleEndian.Uint64(b[:])%uint64(len(de))].Name())
+		path := filepath.Join(m.Path, candidates[binary.LittleEndian.Uint64(b[:])%uint64(len(candidates))])
+		return &path
+	}
 
+	if atomic.LoadInt32(&m.sequence) > -1 {
+		return m.queueNext()
 	}
 
-	return &path
+	f, _ := os.Open(m.Path)
+	de, err := f.ReadDir(1000)
+	f.Close()
+	if err != nil {
+		return nil
+	}
 
+	for _, e := range de { // head from directory order
+		if e.Type().IsRegular() && !isIndexFile(e.Name()) {
+			path := filepath.Join(m.Path, e.Name())
+			return &path
+		}
+	}
+
+	return nil
 }
 
 // Start the ttl ticket expiration manager and immediately call m.Expire(nil) which
-// sets default ttl before entering the ticker loop; aborts when in queue mode
+// sets default ttl before entering the ticker loop; runs in both queue and
+// non-queue mode, since queue mode still needs the hourly segment compaction
 func (m *Ticket) Start(ctx context.Context) {
 
-	// abort when in queue mode; -1
-	if atomic.LoadInt32(&m.sequence) < 0 {
-		return
-	}
-
 	// defaults; when not set by NewTicket
 	if len(m.Path) == 0 {
 		m.Path = "/tmp"
@@ -153,9 +242,11 @@ func (m *Ticket) Start(ctx context.Context) {
 	if _, err := os.Stat(m.Path); !errors.Is(err, fs.ErrExist) {
 		os.MkdirAll(m.Path, 0755)
 	}
+	m.loadTTLIndex()
+	m.loadHeadCursor()
 	m.Expire(nil)
 
-	// check hourly for expirations
+	// check hourly for expirations and queue segment compaction
 	ticker := time.NewTicker(time.Hour)
 	for {
 		select {
@@ -165,40 +256,58 @@ func (m *Ticket) Start(ctx context.Context) {
 
 		case <-ticker.C:
 			m.Expire(nil)
+			m.compactQueueSegments()
 		}
 	}
 
 }
 
-// Expire aged tickets in ticket.Path now; nil sets default 12hr
-// when not already set or age sets ttl to timeframe specified
-func (m *Ticket) Expire(age *time.Duration) *Ticket {
+// defaultTTL sets m.ttl to 12hr when not already set, floors it at 1hr,
+// and returns it; shared by Expire and Writer's fallback expiration
+func (m *Ticket) defaultTTL() time.Duration {
 
-	if age == nil && m.ttl == 0 {
+	if m.ttl == 0 {
 		m.ttl = time.Hour * 12
 	}
+	if m.ttl < time.Hour {
+		m.ttl = time.Hour
+	}
+
+	return m.ttl
+}
+
+// Expire aged tickets in ticket.Path now; nil sets default 12hr
+// when not already set or age sets ttl to timeframe specified.
+// Expirations are read from the persisted ttl index (see ttl.go)
+// rather than file mtime, so per-ticket ttls (Writer, Save) are honored.
+func (m *Ticket) Expire(age *time.Duration) *Ticket {
 
 	if age != nil && *age > 0 {
 		m.ttl = *age
 	}
+	m.defaultTTL()
 
-	if m.ttl < time.Hour {
-		m.ttl = time.Hour
+	now := time.Now().Unix()
+
+	m.ttlMu.Lock()
+	var expired []string
+	for ticket, expiry := range m.ttlIndex {
+		if expiry <= now {
+			expired = append(expired, ticket)
+		}
+	}
+	for _, ticket := range expired {
+		delete(m.ttlIndex, ticket)
 	}
+	m.ttlMu.Unlock()
 
-	info, err := os.ReadDir(m.Path)
-	if err != nil {
-		return nil
+	for _, ticket := range expired {
+		os.Remove(m.getPath(ticket))
+		m.appendTTLRecord(ticket, -1)
 	}
 
-	now := time.Now().Truncate(time.Second)
-	for i := range info {
-		if info[i].Type().IsRegular() {
-			if fin, err := info[i].Info(); err == nil &&
-				fin.ModTime().Add(m.ttl).Before(now) {
-				os.Remove(filepath.Join(m.Path, info[i].Name()))
-			}
-		}
+	if len(expired) > 0 {
+		m.compactTTLIndex()
 	}
 
 	return m
@@ -0,0 +1,162 @@
+package ticket
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ttlIndexFile is the flat, append-only index persisted in m.Path that backs
+// per-ticket expirations; in the same spirit as bitcask's ttl_index.
+//
+// record: uint32 keyLen | key bytes | int64 unixExpiry, all big-endian.
+// A negative unixExpiry tombstones a previously written key.
+const ttlIndexFile = ".ticket.ttl"
+
+func (m *Ticket) ttlIndexPath() string { return filepath.Join(m.Path, ttlIndexFile) }
+
+// loadTTLIndex replays the on-disk ttl index into the in-memory map so
+// Expire can consult real expirations instead of file mtime; called once
+// from Start.
+func (m *Ticket) loadTTLIndex() {
+
+	f, err := os.Open(m.ttlIndexPath())
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	m.ttlMu.Lock()
+	defer m.ttlMu.Unlock()
+
+	if m.ttlIndex == nil {
+		m.ttlIndex = make(map[string]int64)
+	}
+
+	var lenbuf [4]byte
+	var expbuf [8]byte
+	for {
+		if _, err := io.ReadFull(f, lenbuf[:]); err != nil {
+			return
+		}
+
+		key := make([]byte, binary.BigEndian.Uint32(lenbuf[:]))
+		if _, err := io.ReadFull(f, key); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(f, expbuf[:]); err != nil {
+			return
+		}
+
+		if expiry := int64(binary.BigEndian.Uint64(expbuf[:])); expiry < 0 {
+			delete(m.ttlIndex, string(key))
+		} else {
+			m.ttlIndex[string(key)] = expiry
+		}
+	}
+}
+
+// appendTTLRecord appends a single ttl index record for ticket; a negative
+// expiry tombstones the key.
+func (m *Ticket) appendTTLRecord(ticket string, expiry int64) {
+
+	f, err := os.OpenFile(m.ttlIndexPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var lenbuf [4]byte
+	var expbuf [8]byte
+	binary.BigEndian.PutUint32(lenbuf[:], uint32(len(ticket)))
+	binary.BigEndian.PutUint64(expbuf[:], uint64(expiry))
+
+	f.Write(lenbuf[:])
+	f.WriteString(ticket)
+	f.Write(expbuf[:])
+}
+
+// setExpiry records ticket's live expiration in memory and appends it to
+// the on-disk ttl index.
+func (m *Ticket) setExpiry(ticket string, expiresAt time.Time) {
+
+	m.ttlMu.Lock()
+	if m.ttlIndex == nil {
+		m.ttlIndex = make(map[string]int64)
+	}
+	m.ttlIndex[ticket] = expiresAt.Unix()
+	m.ttlMu.Unlock()
+
+	m.appendTTLRecord(ticket, expiresAt.Unix())
+}
+
+// clearExpiry drops ticket from the live index and tombstones it on disk.
+func (m *Ticket) clearExpiry(ticket string) {
+
+	m.ttlMu.Lock()
+	_, ok := m.ttlIndex[ticket]
+	delete(m.ttlIndex, ticket)
+	m.ttlMu.Unlock()
+
+	if ok {
+		m.appendTTLRecord(ticket, -1)
+	}
+}
+
+// compactTTLIndex rewrites the on-disk ttl index down to the live entries
+// only, swapping it in atomically via rename.
+func (m *Ticket) compactTTLIndex() {
+
+	m.ttlMu.Lock()
+	defer m.ttlMu.Unlock()
+
+	tmp, err := os.CreateTemp(m.Path, ".ticket.ttl.*")
+	if err != nil {
+		return
+	}
+
+	var lenbuf [4]byte
+	var expbuf [8]byte
+	for ticket, expiry := range m.ttlIndex {
+		binary.BigEndian.PutUint32(lenbuf[:], uint32(len(ticket)))
+		binary.BigEndian.PutUint64(expbuf[:], uint64(expiry))
+		tmp.Write(lenbuf[:])
+		tmp.WriteString(ticket)
+		tmp.Write(expbuf[:])
+	}
+
+	name := tmp.Name()
+	tmp.Close()
+	os.Rename(name, m.ttlIndexPath())
+}
+
+// ExpiresAt reports the live expiration recorded for ticket, if any.
+func (m *Ticket) ExpiresAt(ticket *string) (time.Time, bool) {
+
+	m.ttlMu.RLock()
+	expiry, ok := m.ttlIndex[*ticket]
+	m.ttlMu.RUnlock()
+
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(expiry, 0), true
+}
+
+// Touch extends a live ticket's expiration by extra, persisting the change;
+// reports false when ticket has no recorded expiration.
+func (m *Ticket) Touch(ticket *string, extra time.Duration) bool {
+
+	m.ttlMu.RLock()
+	expiry, ok := m.ttlIndex[*ticket]
+	m.ttlMu.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	m.setExpiry(*ticket, time.Unix(expiry, 0).Add(extra))
+	return true
+}
@@ -0,0 +1,72 @@
+package ticket
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTLPersistsAcrossReload(t *testing.T) {
+
+	dir := t.TempDir()
+
+	m1 := NewTicket(dir)
+	ttl := time.Hour
+	name, ok := m1.Save(nil, strings.NewReader("payload"), &ttl)
+	if !ok {
+		t.Fatal("save failed")
+	}
+
+	want, ok := m1.ExpiresAt(&name)
+	if !ok {
+		t.Fatal("expected expiry to be recorded")
+	}
+
+	m2 := NewTicket(dir)
+	m2.loadTTLIndex()
+
+	got, ok := m2.ExpiresAt(&name)
+	if !ok {
+		t.Fatal("expiry not persisted across reload")
+	}
+	if !got.Equal(want) {
+		t.Fatalf("expiry mismatch after reload: got %v want %v", got, want)
+	}
+}
+
+func TestTouchExtendsExpiry(t *testing.T) {
+
+	m := NewTicket(t.TempDir())
+	ttl := time.Hour
+	name, ok := m.Save(nil, strings.NewReader("payload"), &ttl)
+	if !ok {
+		t.Fatal("save failed")
+	}
+
+	before, _ := m.ExpiresAt(&name)
+	if !m.Touch(&name, time.Hour) {
+		t.Fatal("touch reported no live ticket")
+	}
+
+	after, _ := m.ExpiresAt(&name)
+	if !after.After(before) {
+		t.Fatalf("touch did not extend expiry: before=%v after=%v", before, after)
+	}
+}
+
+func TestExpireConsultsIndexNotMtime(t *testing.T) {
+
+	m := NewTicket(t.TempDir())
+	aged := -time.Hour
+	name, ok := m.Save(nil, strings.NewReader("payload"), &aged)
+	if !ok {
+		t.Fatal("save failed")
+	}
+
+	m.Expire(nil)
+
+	if _, err := os.Stat(m.getPath(name)); !os.IsNotExist(err) {
+		t.Fatalf("expected aged ticket to be removed, stat err=%v", err)
+	}
+}
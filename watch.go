@@ -0,0 +1,265 @@
+package ticket
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// pollInterval is the fallback cadence used on platforms without
+// inotify/kqueue support.
+const pollInterval = time.Second
+
+// watchPaths returns the directories that need watching/draining for
+// Wait and Subscribe: m.Path itself in the flat layout, or every shard
+// directory under it when Shard is set, since tickets never land in
+// m.Path directly once sharded.
+func (m *Ticket) watchPaths() []string {
+	if m.shards <= 0 {
+		return []string{m.Path}
+	}
+	dirs := m.shardDirs()
+	paths := make([]string, len(dirs))
+	for i, dir := range dirs {
+		paths[i] = filepath.Join(m.Path, dir)
+	}
+	return paths
+}
+
+// Wait blocks until a ticket is available, or until ctx is done, and returns
+// what Next(false) returns for it: in queue mode the ticket name, in FIFO
+// order; otherwise the ticket's path, taken in directory order.
+func (m *Ticket) Wait(ctx context.Context) (*string, error) {
+
+	// install the watcher before the initial check so a ticket saved in
+	// between isn't missed: it either shows up in that check or arrives
+	// as a buffered Create event handled by the loop below
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return m.waitPoll(ctx)
+	}
+	defer watcher.Close()
+
+	for _, path := range m.watchPaths() {
+		if err := watcher.Add(path); err != nil {
+			return m.waitPoll(ctx)
+		}
+	}
+
+	if t := m.Next(false); t != nil {
+		return t, nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return m.waitPoll(ctx)
+			}
+			if ev.Op&fsnotify.Create == 0 || isIndexFile(filepath.Base(ev.Name)) {
+				continue
+			}
+			if t := m.Next(false); t != nil {
+				return t, nil
+			}
+
+		case <-watcher.Errors:
+			continue
+		}
+	}
+}
+
+// waitPoll is the fallback wait loop for platforms without inotify/kqueue
+// support.
+func (m *Ticket) waitPoll(ctx context.Context) (*string, error) {
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+			if t := m.Next(false); t != nil {
+				return t, nil
+			}
+		}
+	}
+}
+
+// Subscribe streams new ticket names as they're saved, closing the returned
+// channel when ctx is done. Pre-existing tickets are drained first so
+// nothing saved between a prior Save and Subscribe being called is missed;
+// in queue mode the stream respects FIFO order (see Next).
+func (m *Ticket) Subscribe(ctx context.Context) <-chan string {
+
+	out := make(chan string, 64)
+	go m.subscribe(ctx, out)
+	return out
+}
+
+func (m *Ticket) subscribe(ctx context.Context, out chan<- string) {
+
+	defer close(out)
+
+	// install the watcher before draining what's already pending, so a
+	// ticket saved in between lands as a buffered Create event instead of
+	// being missed entirely; seen dedups a name that turns up in both
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		m.pollLoop(ctx, out)
+		return
+	}
+	defer watcher.Close()
+
+	for _, path := range m.watchPaths() {
+		if err := watcher.Add(path); err != nil {
+			m.pollLoop(ctx, out)
+			return
+		}
+	}
+
+	seen := make(map[string]bool)
+	if !m.drainPending(ctx, out, seen) {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				m.pollLoop(ctx, out)
+				return
+			}
+
+			name := filepath.Base(ev.Name)
+			if ev.Op&fsnotify.Create == 0 || isIndexFile(name) {
+				continue
+			}
+
+			if atomic.LoadInt32(&m.sequence) > -1 {
+				if !m.drainQueue(ctx, out) {
+					return
+				}
+				continue
+			}
+
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			select {
+			case out <- name:
+			case <-ctx.Done():
+				return
+			}
+
+		case <-watcher.Errors:
+			continue
+		}
+	}
+}
+
+// drainPending empties whatever is already pending before the watcher's
+// buffered events are processed: the durable FIFO log in queue mode, or a
+// directory snapshot of every watched path otherwise (see watchPaths).
+// Outside queue mode, seen is populated with every name emitted so a
+// buffered Create event for the same file isn't sent twice. Reports false if ctx ended while draining.
+func (m *Ticket) drainPending(ctx context.Context, out chan<- string, seen map[string]bool) bool {
+
+	if atomic.LoadInt32(&m.sequence) > -1 {
+		return m.drainQueue(ctx, out)
+	}
+
+	for _, path := range m.watchPaths() {
+		de, err := os.ReadDir(path)
+		if err != nil {
+			continue
+		}
+
+		for _, e := range de {
+			if !e.Type().IsRegular() || isIndexFile(e.Name()) {
+				continue
+			}
+			seen[e.Name()] = true
+			select {
+			case out <- e.Name():
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// drainQueue pushes every ticket currently pending in the durable FIFO log.
+func (m *Ticket) drainQueue(ctx context.Context, out chan<- string) bool {
+
+	for {
+		t := m.queueNext()
+		if t == nil {
+			return true
+		}
+		select {
+		case out <- *t:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// pollLoop is the fallback event source for platforms without
+// inotify/kqueue support; outside queue mode it tracks names it has
+// already emitted so a ticket isn't resent on every tick.
+func (m *Ticket) pollLoop(ctx context.Context, out chan<- string) {
+
+	seen := make(map[string]bool)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if atomic.LoadInt32(&m.sequence) > -1 {
+				if !m.drainQueue(ctx, out) {
+					return
+				}
+				continue
+			}
+
+			for _, path := range m.watchPaths() {
+				de, err := os.ReadDir(path)
+				if err != nil {
+					continue
+				}
+				for _, e := range de {
+					if !e.Type().IsRegular() || isIndexFile(e.Name()) || seen[e.Name()] {
+						continue
+					}
+					seen[e.Name()] = true
+					select {
+					case out <- e.Name():
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}
+}
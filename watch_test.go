@@ -0,0 +1,83 @@
+package ticket
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscribeDeliversSavedTicket(t *testing.T) {
+
+	m := NewTicket(t.TempDir())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out := m.Subscribe(ctx)
+
+	name, ok := m.Save(nil, strings.NewReader("x"), nil)
+	if !ok {
+		t.Fatal("save failed")
+	}
+
+	select {
+	case got := <-out:
+		if got != name {
+			t.Fatalf("got %q, want %q", got, name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for subscribed ticket")
+	}
+}
+
+// TestSubscribeDrainWindowNoDropOrDuplicate saves tickets before Subscribe
+// is called (so they must come from drainPending) and one right after (so
+// it may arrive via drainPending or as a buffered Create event, depending
+// on exactly when the watcher observes it), then checks every ticket was
+// delivered exactly once: nothing lost to the drain/watch handoff, and
+// nothing double-sent because it showed up in both.
+func TestSubscribeDrainWindowNoDropOrDuplicate(t *testing.T) {
+
+	m := NewTicket(t.TempDir())
+
+	want := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		name, ok := m.Save(nil, strings.NewReader("x"), nil)
+		if !ok {
+			t.Fatal("save failed")
+		}
+		want[name] = true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out := m.Subscribe(ctx)
+
+	name, ok := m.Save(nil, strings.NewReader("x"), nil)
+	if !ok {
+		t.Fatal("save failed")
+	}
+	want[name] = true
+
+	got := make(map[string]int)
+	for len(got) < len(want) {
+		select {
+		case name, ok := <-out:
+			if !ok {
+				t.Fatalf("channel closed early with %d/%d tickets delivered", len(got), len(want))
+			}
+			got[name]++
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out with %d/%d tickets delivered", len(got), len(want))
+		}
+	}
+
+	for name, count := range got {
+		if !want[name] {
+			t.Fatalf("delivered unexpected ticket %q", name)
+		}
+		if count != 1 {
+			t.Fatalf("ticket %q delivered %d times, want 1", name, count)
+		}
+	}
+}